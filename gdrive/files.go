@@ -16,6 +16,7 @@ import (
 	"net/http"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"google.golang.org/api/drive/v3"
 	"google.golang.org/api/googleapi"
@@ -25,7 +26,7 @@ import (
 // https://developers.google.com/drive/api/v3/ref-export-formats
 var exportMap = map[string]string{
 	"spreadsheet": "text/csv",
-	"drawing":     "text/svg",
+	"drawing":     "image/svg+xml",
 }
 
 // Define an interface so we can mock the FilesService type for testing if we
@@ -39,10 +40,42 @@ type driveFiler interface {
 	Update(fileId string, file *drive.File) *drive.FilesUpdateCall
 }
 
+// driveAbouter mirrors drive.AboutService so it can be mocked for testing.
+type driveAbouter interface {
+	Get() *drive.AboutGetCall
+}
+
+// driveChanger mirrors drive.ChangesService so it can be mocked for testing.
+type driveChanger interface {
+	GetStartPageToken() *drive.ChangesGetStartPageTokenCall
+	List(pageToken string) *drive.ChangesListCall
+}
+
+// driveLister mirrors drive.DrivesService so it can be mocked for testing.
+type driveLister interface {
+	List() *drive.DrivesListCall
+}
+
+// svcInitMu guards the lazy, on-first-use creation of a Service's aboutState
+// and cache pointers (see about() and pathCache()). It's a single package-
+// level lock rather than a field on Service so that WithSharedDrive can
+// still make a plain shallow copy of Service without copying a lock.
+var svcInitMu sync.Mutex
+
 // Service wraps drive.FilesService
 type Service struct {
-	ctx   context.Context
-	filer driveFiler
+	ctx     context.Context
+	filer   driveFiler
+	abouter driveAbouter
+	changer driveChanger
+	drives  driveLister
+
+	exportExts    []string
+	importFormats map[string]string
+	chunkSize     int64
+	cache         *PathCache
+	driveID       string
+	aboutState    *aboutCache
 }
 
 // NewServiceWithCtx creates and wraps a new FilesService with the provided
@@ -53,13 +86,19 @@ func NewServiceWithCtx(ctx context.Context) (*Service, error) {
 		return nil, err
 	}
 	return &Service{
-		ctx:   ctx,
-		filer: gsvc.Files,
+		ctx:     ctx,
+		filer:   gsvc.Files,
+		abouter: gsvc.About,
+		changer: gsvc.Changes,
+		drives:  gsvc.Drives,
 	}, nil
 }
 
 func (svc *Service) WithService(service *drive.Service) {
 	svc.filer = service.Files
+	svc.abouter = service.About
+	svc.changer = service.Changes
+	svc.drives = service.Drives
 }
 
 // FilesService returns a pointer to the wrapped FilesService
@@ -80,6 +119,9 @@ func (svc *Service) Search(q string) ([]*drive.File, error) {
 	}
 
 	listCall := svc.filer.List().Fields("files(id, name, parents, shared)").SupportsAllDrives(true).IncludeTeamDriveItems(true).Q(q)
+	if svc.driveID != "" {
+		listCall.Corpora("drive").DriveId(svc.driveID).IncludeItemsFromAllDrives(true)
+	}
 	err := listCall.Pages(svc.ctx, pages)
 	if err == nil {
 		_, err = listCall.Do()
@@ -116,8 +158,12 @@ func (svc *Service) CreateFolder(name, parent string) (*drive.File, error) {
 		Name:     name,
 		MimeType: "application/vnd.google-apps.folder",
 		Parents:  []string{parent},
-	})
-	return createCall.Do()
+	}).SupportsAllDrives(true)
+	folder, err := createCall.Do()
+	if err == nil {
+		svc.pathCache().invalidate(parent, name)
+	}
+	return folder, err
 }
 
 // On Windows the mime.TypeByExtension method can return wrong values
@@ -133,33 +179,57 @@ func typeByExtension(ext string) string {
 
 // CreateFile creats a new file named 'name' in folder with id 'parent' and
 // content read from 'src'.
-// If name has '.csv' extension, then the created file is converted to a Google
-// Sheets document on the drive.
+// If name's extension is a recognized Google Workspace import format (see
+// SetImportFormats), the created file is converted to the corresponding
+// Google Workspace document on the drive.
 // If parent is empty, file will be created in user's drive root.
 // If 'src' is nil, creates an empty file.
 // (This will not overwrite any other files with the same name.)
 // https://developers.google.com/drive/api/v3/create-file
 func (svc *Service) CreateFile(name, parent string, src io.Reader) (*drive.File, error) {
+	return svc.createFile(name, parent, src, nil)
+}
+
+// createFile is the shared implementation behind CreateFile and
+// CreateOrUpdateFile's create path. appProperties, if non-nil, is attached
+// to the created file (used to stamp the source hash; see UpdateOptions).
+func (svc *Service) createFile(name, parent string, src io.Reader, appProperties map[string]string) (*drive.File, error) {
 	ext := filepath.Ext(name)
 	mime := typeByExtension(ext)
-	var gmime string
-	if strings.Contains(mime, "text/csv") {
-		gmime = "application/vnd.google-apps.spreadsheet"
-	}
+	gmime := svc.gmimeForImport(ext, mime)
 	createCall := svc.filer.Create(&drive.File{
-		Name:     name,
-		MimeType: gmime,
-		Parents:  []string{parent},
-	})
+		Name:          name,
+		MimeType:      gmime,
+		Parents:       []string{parent},
+		AppProperties: appProperties,
+	}).SupportsAllDrives(true)
+
+	var file *drive.File
+	var err error
 	if src != nil {
+		// Once Media's reader has been read from, it can't be replayed, so
+		// a metadata-bearing upload isn't safe to retry here: rebuilding the
+		// call from a re-seekable source is the caller's job (see
+		// CreateFileWithProgress for the chunked, resumable alternative).
 		createCall.Media(src, googleapi.ContentType(mime))
+		file, err = createCall.Do()
+	} else {
+		err = pace(func() error {
+			var err error
+			file, err = createCall.Do()
+			return err
+		})
+	}
+	if err == nil {
+		svc.pathCache().invalidate(parent, name)
 	}
-	return createCall.Do()
+	return file, err
 }
 
 // CreateOrUpdateFile creates a new file named 'name' (with contents of 'src')
-// if it does not already exist in 'parent'; otherwise it replaces the contents
-// of the existing file.
+// if it does not already exist in 'parent'; otherwise it replaces the
+// contents of the existing file, skipping the upload if the existing file's
+// contents already match 'src' (see UpdateFileIfChanged).
 func (svc *Service) CreateOrUpdateFile(name, parent string,
 	src io.Reader) (*drive.File, error) {
 	var file *drive.File
@@ -182,9 +252,15 @@ func (svc *Service) CreateOrUpdateFile(name, parent string,
 	}
 
 	if len(files) > 0 {
-		file, err = svc.UpdateFile(files[0].Id, name, src)
+		file, err = svc.UpdateFileIfChanged(files[0].Id, name, src, nil)
 	} else {
-		file, err = svc.CreateFile(name, parent, src)
+		buffered, _, _, sha256Hex, herr := hashSource(src)
+		if herr != nil {
+			return nil, herr
+		}
+		file, err = svc.createFile(name, parent, buffered, map[string]string{
+			appPropSourceSHA256: sha256Hex,
+		})
 	}
 	return file, err
 }
@@ -192,44 +268,64 @@ func (svc *Service) CreateOrUpdateFile(name, parent string,
 // UpdateFile replaces an existing drive file (id) the contents read from 'src'
 // and updates its name to 'name'
 func (svc *Service) UpdateFile(id, name string, src io.Reader) (*drive.File, error) {
-	updateCall := svc.filer.Update(id, &drive.File{})
-	if src != nil {
-		ext := filepath.Ext(name)
-		updateCall.Media(src, googleapi.ContentType(typeByExtension(ext)))
-	}
-	return updateCall.Do()
+	return svc.UpdateFileWithOptions(id, name, src, nil)
 }
 
 // GetInfo returns all metadata for the file identified by 'id'
 func (svc *Service) GetInfo(id string) (*drive.File, error) {
-	return svc.filer.Get(id).Fields("*").Do()
+	return svc.filer.Get(id).Fields("*").SupportsAllDrives(true).Do()
 }
 
 // DownloadFile returns a http.Response for downloading the contents of file
 // identified by 'id'.
 // If file is a Google Workspace file it is exported as a text format.
+// (That is the default; formats set with SetExportFormats are tried first,
+// as in DownloadFileAs.)
 // https://developers.google.com/drive/api/v3/manage-downloads
 func (svc *Service) DownloadFile(id string) (*http.Response, error) {
-	var dlFunc func(...googleapi.CallOption) (*http.Response, error)
-	getCall := svc.filer.Get(id)
+	_, resp, err := svc.DownloadFileAs(id)
+	return resp, err
+}
+
+// DownloadFileAs returns the metadata and a http.Response for downloading the
+// contents of file identified by 'id'. If the file is a Google Workspace
+// document, it is exported: the first extension in preferredExts that the
+// document actually supports exporting to (per about.exportFormats) is used.
+// If preferredExts is empty, the extensions set with SetExportFormats are
+// tried instead; if none of the extensions tried is supported, the package's
+// built-in default is used. When an extension from the list is chosen, it is
+// appended to the returned file's Name; the built-in default appends none.
+// https://developers.google.com/drive/api/v3/manage-downloads
+func (svc *Service) DownloadFileAs(id string, preferredExts ...string) (*drive.File, *http.Response, error) {
+	getCall := svc.filer.Get(id).SupportsAllDrives(true)
 	file, err := getCall.Do()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	if strings.HasPrefix(file.MimeType, "application/vnd.google-apps") {
-		// it is a google workspace doc we must export
-		parts := strings.Split(file.MimeType, ".")
-		driveType := parts[len(parts)-1]
-		mime := exportMap[driveType]
-		if mime == "" {
-			mime = "text/plain"
-		}
-		dlFunc = svc.filer.Export(id, mime).Download
-	} else {
-		// we can download this file
-		dlFunc = getCall.Download
+	if !strings.HasPrefix(file.MimeType, "application/vnd.google-apps") {
+		resp, err := getCall.Download()
+		return file, resp, err
 	}
-	return dlFunc()
+
+	// it is a google workspace doc we must export
+	if len(preferredExts) == 0 {
+		preferredExts = svc.exportExts
+	}
+	mimeType, ext, err := svc.chooseExportMIME(file.MimeType, preferredExts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if mimeType == "" {
+		mimeType = defaultExportMIME(file.MimeType)
+	}
+	resp, err := svc.filer.Export(id, mimeType).Download()
+	if err != nil {
+		return nil, nil, err
+	}
+	if ext != "" {
+		file.Name += ext
+	}
+	return file, resp, nil
 }
 
 // FileContents downloads and returns the contents of the file identified by
@@ -245,5 +341,11 @@ func (svc *Service) FileContents(id string) ([]byte, error) {
 
 // DeleteFile deletes file identified by 'id'
 func (svc *Service) DeleteFile(id string) error {
-	return svc.filer.Delete(id).Do()
+	err := svc.filer.Delete(id).SupportsAllDrives(true).Do()
+	if err == nil {
+		// DeleteFile isn't told the file's path, so it can't invalidate a
+		// single cache entry precisely; drop everything instead.
+		svc.pathCache().clear()
+	}
+	return err
 }
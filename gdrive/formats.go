@@ -0,0 +1,160 @@
+package gdrive
+
+import (
+	"mime"
+	"strings"
+	"sync"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// aboutCache holds the lazily-fetched, process-lifetime result of an
+// about() call. It's held behind a pointer (rather than embedding sync.Once
+// directly in Service) so that WithSharedDrive can make a shallow copy of
+// Service without copying a lock, and so the two copies share one cache:
+// export/import format support doesn't vary by Shared Drive.
+type aboutCache struct {
+	once sync.Once
+	info *drive.About
+	err  error
+}
+
+// extMIMEs maps well-known extensions to the MIME type Drive registers them
+// under in about.exportFormats/about.importFormats. mime.TypeByExtension
+// doesn't reliably know these across platforms, so we hardcode them.
+// https://developers.google.com/drive/api/v3/ref-export-formats
+var extMIMEs = map[string]string{
+	".csv":  "text/csv",
+	".tsv":  "text/tab-separated-values",
+	".svg":  "image/svg+xml",
+	".pdf":  "application/pdf",
+	".txt":  "text/plain",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".ods":  "application/vnd.oasis.opendocument.spreadsheet",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".odt":  "application/vnd.oasis.opendocument.text",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+}
+
+// defaultImportFormats maps import extensions to the Google Workspace MIME
+// type CreateFile converts them to when no about.importFormats entry and no
+// caller-provided SetImportFormats entry matches.
+var defaultImportFormats = map[string]string{
+	".csv":  "application/vnd.google-apps.spreadsheet",
+	".tsv":  "application/vnd.google-apps.spreadsheet",
+	".xlsx": "application/vnd.google-apps.spreadsheet",
+	".ods":  "application/vnd.google-apps.spreadsheet",
+	".docx": "application/vnd.google-apps.document",
+	".odt":  "application/vnd.google-apps.document",
+	".pptx": "application/vnd.google-apps.presentation",
+	".svg":  "application/vnd.google-apps.drawing",
+}
+
+// SetExportFormats configures the extensions (e.g. "xlsx", "pdf") that
+// DownloadFileAs prefers, in order, when no preferredExts are passed
+// explicitly. Overrides the package's built-in export choice.
+func (svc *Service) SetExportFormats(exts []string) {
+	svc.exportExts = exts
+}
+
+// SetImportFormats configures which Google Workspace MIME type CreateFile
+// converts a source extension (e.g. ".xlsx") to. Entries here take priority
+// over about.importFormats and the package's built-in defaults.
+func (svc *Service) SetImportFormats(formats map[string]string) {
+	svc.importFormats = formats
+}
+
+// about lazily fetches and caches the authenticated user's export/import
+// format support; it's requested once per Service since it doesn't change
+// within a process lifetime.
+func (svc *Service) about() (*drive.About, error) {
+	svcInitMu.Lock()
+	if svc.aboutState == nil {
+		svc.aboutState = &aboutCache{}
+	}
+	state := svc.aboutState
+	svcInitMu.Unlock()
+
+	state.once.Do(func() {
+		state.info, state.err = svc.abouter.Get().
+			Fields("exportFormats,importFormats").Do()
+	})
+	return state.info, state.err
+}
+
+// extMIME returns the MIME type ext canonically maps to.
+func extMIME(ext string) string {
+	if m, ok := extMIMEs[ext]; ok {
+		return m
+	}
+	return mime.TypeByExtension(ext)
+}
+
+// normalizeExt lowercases ext and ensures it has a leading '.', so callers
+// can pass either ".xlsx" or "xlsx".
+func normalizeExt(ext string) string {
+	ext = strings.ToLower(ext)
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
+
+// chooseExportMIME picks the first MIME type among preferredExts that
+// srcMimeType (a Google Workspace document's MimeType) actually supports
+// exporting to, per about.exportFormats. Returns "", "", nil if none match.
+func (svc *Service) chooseExportMIME(srcMimeType string, preferredExts []string) (mimeType, ext string, err error) {
+	if len(preferredExts) == 0 {
+		return "", "", nil
+	}
+	about, err := svc.about()
+	if err != nil {
+		return "", "", err
+	}
+	supported := about.ExportFormats[srcMimeType]
+	for _, e := range preferredExts {
+		e = normalizeExt(e)
+		candidate := extMIME(e)
+		for _, m := range supported {
+			if m == candidate {
+				return m, e, nil
+			}
+		}
+	}
+	return "", "", nil
+}
+
+// defaultExportMIME reproduces the package's original hard-coded export
+// choice (spreadsheet -> CSV, drawing -> SVG, everything else -> plain text),
+// used when the caller hasn't configured or matched a preferred extension.
+func defaultExportMIME(mimeType string) string {
+	parts := strings.Split(mimeType, ".")
+	driveType := parts[len(parts)-1]
+	m := exportMap[driveType]
+	if m == "" {
+		m = "text/plain"
+	}
+	return m
+}
+
+// gmimeForImport returns the Google Workspace MIME type a file with the
+// given extension and detected srcMIME should be converted to on upload, or
+// "" if it shouldn't be converted. Consults, in order: the caller's
+// SetImportFormats table, about.importFormats, and the package's built-in
+// defaults.
+func (svc *Service) gmimeForImport(ext, srcMIME string) string {
+	ext = normalizeExt(ext)
+	if svc.importFormats != nil {
+		if g, ok := svc.importFormats[ext]; ok {
+			return g
+		}
+	}
+	if about, err := svc.about(); err == nil {
+		for _, target := range about.ImportFormats[srcMIME] {
+			if strings.HasPrefix(target, "application/vnd.google-apps") {
+				return target
+			}
+		}
+	}
+	return defaultImportFormats[ext]
+}
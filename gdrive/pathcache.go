@@ -0,0 +1,240 @@
+package gdrive
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// rootID is Drive's alias for the authenticated user's My Drive root folder.
+// https://developers.google.com/drive/api/v3/folder#creating_folders
+const rootID = "root"
+
+// rootParent is the folder ID path resolution starts walking from: the
+// Shared Drive's own ID when svc is scoped to one via WithSharedDrive
+// (a Shared Drive's top-level folder is the drive itself), or My Drive's
+// root otherwise.
+func (svc *Service) rootParent() string {
+	if svc.driveID != "" {
+		return svc.driveID
+	}
+	return rootID
+}
+
+// pathKey identifies a (parent folder, name) pair. Drive allows multiple
+// files with the same name in one parent, and the same file to live under
+// multiple parents, so caching by ID alone can't answer "what's named X in
+// folder Y".
+type pathKey struct {
+	parent string
+	name   string
+}
+
+// PathCache resolves '/'-separated paths (e.g. "/a/b/c.csv") to Drive files,
+// populating itself lazily via Search and invalidated as the owning
+// Service's Create/Update/Delete methods change the tree. Drive file names
+// containing a literal '/' can't be represented in this package's path
+// syntax and won't be found by path lookups.
+type PathCache struct {
+	svc *Service
+
+	mu    sync.Mutex
+	byKey map[pathKey][]*drive.File
+}
+
+// pathCache returns the Service's PathCache, creating it on first use.
+func (svc *Service) pathCache() *PathCache {
+	svcInitMu.Lock()
+	defer svcInitMu.Unlock()
+	if svc.cache == nil {
+		svc.cache = &PathCache{svc: svc, byKey: map[pathKey][]*drive.File{}}
+	}
+	return svc.cache
+}
+
+func splitPath(path string) []string {
+	var segs []string
+	for _, s := range strings.Split(path, "/") {
+		if s != "" {
+			segs = append(segs, s)
+		}
+	}
+	return segs
+}
+
+// resolve returns the files named name inside parent, consulting the cache
+// before falling back to a Search.
+func (c *PathCache) resolve(parent, name string) ([]*drive.File, error) {
+	key := pathKey{parent, name}
+	c.mu.Lock()
+	files, ok := c.byKey[key]
+	c.mu.Unlock()
+	if ok {
+		return files, nil
+	}
+
+	files, err := c.svc.FilesNamed(name, parent)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.byKey[key] = files
+	c.mu.Unlock()
+	return files, nil
+}
+
+// invalidate drops the cached entry for (parent, name), forcing the next
+// resolve to re-query Drive.
+func (c *PathCache) invalidate(parent, name string) {
+	c.mu.Lock()
+	delete(c.byKey, pathKey{parent, name})
+	c.mu.Unlock()
+}
+
+// clear drops every cached entry.
+func (c *PathCache) clear() {
+	c.mu.Lock()
+	c.byKey = map[pathKey][]*drive.File{}
+	c.mu.Unlock()
+}
+
+// LookupPathAll resolves path (e.g. "/a/b/c.csv") to every Drive file
+// matching its final segment, walking cached segments and falling back to
+// Search for the ones it hasn't seen yet.
+func (svc *Service) LookupPathAll(path string) ([]*drive.File, error) {
+	segs := splitPath(path)
+	if len(segs) == 0 {
+		return nil, fmt.Errorf("gdrive: empty path %q", path)
+	}
+	cache := svc.pathCache()
+	parent := svc.rootParent()
+	var files []*drive.File
+	for i, name := range segs {
+		var err error
+		files, err = cache.resolve(parent, name)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("gdrive: %q not found", "/"+strings.Join(segs[:i+1], "/"))
+		}
+		parent = files[0].Id
+	}
+	return files, nil
+}
+
+// LookupPath resolves path (e.g. "/a/b/c.csv") to a single Drive file. If
+// more than one file in a folder shares the final segment's name, LookupPath
+// returns an error; use LookupPathAll to get every match instead.
+func (svc *Service) LookupPath(path string) (*drive.File, error) {
+	files, err := svc.LookupPathAll(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) > 1 {
+		return nil, fmt.Errorf("gdrive: %d files named %q, ambiguous path %q", len(files), files[0].Name, path)
+	}
+	return files[0], nil
+}
+
+// MkdirAll ensures every folder named in path exists, creating any that are
+// missing (like os.MkdirAll), and returns the leaf folder.
+func (svc *Service) MkdirAll(path string) (*drive.File, error) {
+	segs := splitPath(path)
+	if len(segs) == 0 {
+		return nil, fmt.Errorf("gdrive: empty path %q", path)
+	}
+	cache := svc.pathCache()
+	parent := svc.rootParent()
+	var folder *drive.File
+	for _, name := range segs {
+		files, err := cache.resolve(parent, name)
+		if err != nil {
+			return nil, err
+		}
+		switch len(files) {
+		case 0:
+			folder, err = svc.CreateFolder(name, parent)
+			if err != nil {
+				return nil, err
+			}
+		case 1:
+			folder = files[0]
+		default:
+			return nil, fmt.Errorf("gdrive: %d files named %q in %q, ambiguous", len(files), name, parent)
+		}
+		parent = folder.Id
+	}
+	return folder, nil
+}
+
+// CreateFileAtPath creates a file at path (e.g. "/a/b/c.csv") with contents
+// read from src, creating any missing intermediate folders as MkdirAll
+// would.
+func (svc *Service) CreateFileAtPath(path string, src io.Reader) (*drive.File, error) {
+	segs := splitPath(path)
+	if len(segs) == 0 {
+		return nil, fmt.Errorf("gdrive: empty path %q", path)
+	}
+	name := segs[len(segs)-1]
+	parentID := svc.rootParent()
+	if len(segs) > 1 {
+		parent, err := svc.MkdirAll("/" + strings.Join(segs[:len(segs)-1], "/"))
+		if err != nil {
+			return nil, err
+		}
+		parentID = parent.Id
+	}
+	return svc.CreateFile(name, parentID, src)
+}
+
+// Walk resolves root to a folder, then calls fn once for every file and
+// folder beneath it (root itself is not visited), doing a single batched,
+// paginated List per directory rather than one Search per file. Folders are
+// visited before their contents. fn's path argument is root-relative and
+// '/'-joined, e.g. "sub/file.csv". If fn returns an error, Walk stops and
+// returns it.
+func (svc *Service) Walk(root string, fn func(path string, f *drive.File) error) error {
+	folder, err := svc.LookupPath(root)
+	if err != nil {
+		return err
+	}
+	return svc.walk(folder.Id, "", fn)
+}
+
+func (svc *Service) walk(parentID, prefix string, fn func(path string, f *drive.File) error) error {
+	var children []*drive.File
+	pages := func(fl *drive.FileList) error {
+		children = append(children, fl.Files...)
+		return nil
+	}
+	listCall := svc.filer.List().
+		Fields("nextPageToken, files(id, name, parents, mimeType)").
+		Q(fmt.Sprintf("'%s' in parents", escapeQuery(parentID))).
+		SupportsAllDrives(true).IncludeItemsFromAllDrives(true)
+	if svc.driveID != "" {
+		listCall.Corpora("drive").DriveId(svc.driveID)
+	}
+	if err := listCall.Pages(svc.ctx, pages); err != nil {
+		return err
+	}
+
+	for _, f := range children {
+		path := f.Name
+		if prefix != "" {
+			path = prefix + "/" + f.Name
+		}
+		if err := fn(path, f); err != nil {
+			return err
+		}
+		if f.MimeType == "application/vnd.google-apps.folder" {
+			if err := svc.walk(f.Id, path, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
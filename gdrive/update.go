@@ -0,0 +1,159 @@
+package gdrive
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// appPropSourceSHA256 is the appProperties key this package stamps on files
+// uploaded through CreateOrUpdateFile and UpdateFileIfChanged, recording the
+// SHA-256 of the source bytes. CreateFile, CreateFileWithProgress, UpdateFile
+// and UpdateFileWithOptions do not write it. It's the only
+// way to detect an unchanged Google Workspace document on a later upload,
+// since Workspace files have no md5Checksum of their own.
+const appPropSourceSHA256 = "gdriveSourceSHA256"
+
+// UpdateOptions configures an update beyond replacing a file's contents and
+// name, for metadata the plain UpdateFile signature has no room for.
+type UpdateOptions struct {
+	// PreserveModifiedTime keeps the file's existing modifiedTime instead of
+	// letting Drive bump it to now.
+	PreserveModifiedTime bool
+	// Description, if non-nil, replaces the file's description.
+	Description *string
+	// AppProperties, if non-nil, replaces the file's private appProperties.
+	AppProperties map[string]string
+	// Properties, if non-nil, replaces the file's shared properties.
+	Properties map[string]string
+}
+
+// hashSource reads all of src, computing its MD5 and SHA-256 digests (and
+// its length) while buffering it in memory, and returns a fresh reader over
+// the same bytes so the caller can still use it for upload afterwards. This
+// buffering is the cost of being able to decide whether to skip an upload
+// before making any network call; it's not suitable for files too large to
+// hold in memory twice over (see CreateFileWithProgress for those).
+func hashSource(src io.Reader) (buffered io.Reader, size int64, md5Hex, sha256Hex string, err error) {
+	if src == nil {
+		return nil, 0, "", "", nil
+	}
+	md5h := md5.New()
+	sha256h := sha256.New()
+	var buf bytes.Buffer
+	if _, err := io.Copy(io.MultiWriter(&buf, md5h, sha256h), src); err != nil {
+		return nil, 0, "", "", err
+	}
+	return bytes.NewReader(buf.Bytes()), int64(buf.Len()),
+		hex.EncodeToString(md5h.Sum(nil)), hex.EncodeToString(sha256h.Sum(nil)), nil
+}
+
+// UpdateFileIfChanged behaves like UpdateFile, but first checks whether the
+// remote file already matches src's contents and, if so, skips the upload
+// (and any metadata write) entirely — saving bandwidth and leaving
+// modifiedTime untouched. For ordinary (non-Workspace) files this compares
+// size and MD5 against the remote's md5Checksum. Google Workspace files
+// (Docs, Sheets, ...) have no MD5, so for those this instead compares
+// against the SHA-256 this package stores in an appProperties entry on each
+// successful upload through CreateOrUpdateFile or UpdateFileIfChanged; a
+// Workspace file without that entry is treated as changed for any non-nil src.
+func (svc *Service) UpdateFileIfChanged(id, name string, src io.Reader, opts *UpdateOptions) (*drive.File, error) {
+	buffered, size, md5Hex, sha256Hex, err := hashSource(src)
+	if err != nil {
+		return nil, err
+	}
+
+	remote, err := svc.filer.Get(id).
+		Fields("id,name,mimeType,md5Checksum,size,modifiedTime,appProperties").
+		SupportsAllDrives(true).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	var unchanged bool
+	switch {
+	case remote.Md5Checksum != "":
+		unchanged = remote.Md5Checksum == md5Hex && remote.Size == size
+	case strings.HasPrefix(remote.MimeType, "application/vnd.google-apps"):
+		unchanged = remote.AppProperties[appPropSourceSHA256] == sha256Hex
+	}
+	if unchanged {
+		return remote, nil
+	}
+
+	if opts == nil {
+		opts = &UpdateOptions{}
+	}
+	opts = opts.withSourceHash(sha256Hex)
+	return svc.UpdateFileWithOptions(id, name, buffered, opts)
+}
+
+// withSourceHash returns a copy of opts with its AppProperties extended to
+// include the source SHA-256, preserving any entries the caller already set.
+func (opts *UpdateOptions) withSourceHash(sha256Hex string) *UpdateOptions {
+	out := *opts
+	props := make(map[string]string, len(opts.AppProperties)+1)
+	for k, v := range opts.AppProperties {
+		props[k] = v
+	}
+	props[appPropSourceSHA256] = sha256Hex
+	out.AppProperties = props
+	return &out
+}
+
+// UpdateFileWithOptions replaces an existing drive file (id) with the
+// contents read from 'src', updates its name to 'name', and applies any
+// metadata changes requested by opts. A nil opts behaves like UpdateFile.
+func (svc *Service) UpdateFileWithOptions(id, name string, src io.Reader, opts *UpdateOptions) (*drive.File, error) {
+	file := &drive.File{}
+	if opts != nil {
+		if opts.PreserveModifiedTime {
+			remote, err := svc.filer.Get(id).Fields("modifiedTime").SupportsAllDrives(true).Do()
+			if err != nil {
+				return nil, err
+			}
+			file.ModifiedTime = remote.ModifiedTime
+		}
+		if opts.Description != nil {
+			file.Description = *opts.Description
+		}
+		if opts.AppProperties != nil {
+			file.AppProperties = opts.AppProperties
+		}
+		if opts.Properties != nil {
+			file.Properties = opts.Properties
+		}
+	}
+
+	updateCall := svc.filer.Update(id, file).SupportsAllDrives(true)
+
+	var result *drive.File
+	var err error
+	if src != nil {
+		// Once Media's reader has been read from, it can't be replayed, so
+		// a metadata-bearing upload isn't safe to retry here: rebuilding the
+		// call from a re-seekable source is the caller's job.
+		ext := filepath.Ext(name)
+		updateCall.Media(src, googleapi.ContentType(typeByExtension(ext)))
+		result, err = updateCall.Do()
+	} else {
+		err = pace(func() error {
+			var err error
+			result, err = updateCall.Do()
+			return err
+		})
+	}
+	if err == nil {
+		// UpdateFileWithOptions isn't told the file's path, so it can't
+		// invalidate a single cache entry precisely; drop everything instead.
+		svc.pathCache().clear()
+	}
+	return result, err
+}
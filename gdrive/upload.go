@@ -0,0 +1,135 @@
+package gdrive
+
+import (
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	// defaultChunkSize is used until the caller calls SetChunkSize.
+	defaultChunkSize = 8 * 1024 * 1024 // 8 MiB
+
+	// chunkSizeMultiple is the boundary resumable upload chunk sizes must be
+	// a multiple of.
+	// https://pkg.go.dev/google.golang.org/api/googleapi#ChunkSize
+	chunkSizeMultiple = 256 * 1024 // 256 KiB
+
+	maxUploadRetries = 5
+)
+
+// retryableCodes are the googleapi.Error HTTP status codes worth retrying:
+// they indicate a transient condition (rate limiting, a server hiccup)
+// rather than a permanent rejection of the request.
+var retryableCodes = map[int]bool{
+	408: true,
+	429: true,
+	500: true,
+	502: true,
+	503: true,
+	504: true,
+}
+
+// retryable403Reasons are the only 403 sub-reasons worth retrying; other
+// 403s (e.g. insufficientPermissions) are permanent and shouldn't be paced.
+var retryable403Reasons = map[string]bool{
+	"userRateLimitExceeded": true,
+	"rateLimitExceeded":     true,
+}
+
+func isRetryable(err error) bool {
+	var gerr *googleapi.Error
+	if !errors.As(err, &gerr) {
+		return false
+	}
+	if gerr.Code == 403 {
+		for _, e := range gerr.Errors {
+			if retryable403Reasons[e.Reason] {
+				return true
+			}
+		}
+		return false
+	}
+	return retryableCodes[gerr.Code]
+}
+
+// pace calls do, retrying with exponential backoff and jitter as long as it
+// returns a transient googleapi.Error, up to maxUploadRetries times.
+func pace(do func() error) error {
+	var err error
+	for attempt := 0; attempt <= maxUploadRetries; attempt++ {
+		if err = do(); err == nil || !isRetryable(err) {
+			return err
+		}
+		backoff := time.Duration(math.Pow(2, float64(attempt))) * time.Second
+		jitter := time.Duration(rand.Int63n(int64(backoff)/2 + 1))
+		time.Sleep(backoff + jitter)
+	}
+	return err
+}
+
+// SetChunkSize configures the chunk size used by CreateFileWithProgress's
+// resumable upload. size is rounded up to the nearest multiple of 256 KiB,
+// as required by googleapi.ChunkSize. The default is 8 MiB.
+func (svc *Service) SetChunkSize(size int64) {
+	if size <= 0 {
+		size = defaultChunkSize
+	}
+	if rem := size % chunkSizeMultiple; rem != 0 {
+		size += chunkSizeMultiple - rem
+	}
+	svc.chunkSize = size
+}
+
+func (svc *Service) chunkSizeOrDefault() int64 {
+	if svc.chunkSize > 0 {
+		return svc.chunkSize
+	}
+	return defaultChunkSize
+}
+
+// CreateFileWithProgress creates a new file named 'name' in folder with id
+// 'parent' like CreateFile, but uploads 'src' (of 'size' bytes) as a
+// resumable, chunked upload: it's sent in Service.SetChunkSize pieces (8 MiB
+// by default), so a multi-GB upload can survive a flaky connection instead
+// of failing outright. (We use Media with googleapi.ChunkSize rather than a
+// separate ResumableMedia call, since that's what also gets us
+// ProgressUpdater; the wire protocol is the same resumable-upload dance.)
+// Retries of individual chunks, including backoff on transient errors, are
+// handled internally by the chunked-upload protocol itself — as with
+// CreateFile/UpdateFile given a non-nil src, this call is not wrapped in pace,
+// since src has already been partially read by the time any error surfaces
+// and retrying Do() would resend from wherever the reader was left off. If
+// progress is non-nil, it's called after each chunk with the number of
+// bytes sent so far and the total.
+// https://developers.google.com/drive/api/v3/manage-uploads#resumable
+func (svc *Service) CreateFileWithProgress(name, parent string, src io.Reader,
+	size int64, progress func(bytesSent, total int64)) (*drive.File, error) {
+	ext := filepath.Ext(name)
+	mime := typeByExtension(ext)
+	gmime := svc.gmimeForImport(ext, mime)
+	createCall := svc.filer.Create(&drive.File{
+		Name:     name,
+		MimeType: gmime,
+		Parents:  []string{parent},
+	}).SupportsAllDrives(true)
+	createCall.Media(src, googleapi.ContentType(mime),
+		googleapi.ChunkSize(int(svc.chunkSizeOrDefault())))
+	if progress != nil {
+		createCall.ProgressUpdater(func(current, _ int64) {
+			progress(current, size)
+		})
+	}
+
+	file, err := createCall.Do()
+	if err == nil {
+		svc.pathCache().invalidate(parent, name)
+	}
+	return file, err
+}
@@ -0,0 +1,106 @@
+package gdrive
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// changeFields limits changes.list responses to what callers typically need
+// to react to an edit without a second round-trip for metadata.
+const changeFields = "changes(fileId,removed,file(id,name,parents,mimeType,modifiedTime,md5Checksum)),newStartPageToken,nextPageToken"
+
+// TokenStore persists a change feed's page token between Poll runs, so
+// polling can resume where it left off across restarts.
+type TokenStore interface {
+	Load() (string, error)
+	Save(token string) error
+}
+
+// StartPageToken returns a page token marking the current state of the
+// user's Drive, for use as the starting point of a later Changes call.
+// https://developers.google.com/drive/api/v3/reference/changes/getStartPageToken
+func (svc *Service) StartPageToken() (string, error) {
+	res, err := svc.changer.GetStartPageToken().
+		SupportsAllDrives(true).Do()
+	if err != nil {
+		return "", err
+	}
+	return res.StartPageToken, nil
+}
+
+// Changes pages through every change since token, calling handler once per
+// changed file (handler may be called for files that were removed or whose
+// access was revoked; check Change.Removed). It returns the page token to
+// pass to the next Changes call to pick up where this one left off. If
+// handler or a changes.list request returns an error, Changes stops and
+// returns it along with the token it was called with, so a later call
+// re-reads every change from that point.
+// https://developers.google.com/drive/api/v3/reference/changes/list
+func (svc *Service) Changes(ctx context.Context, token string, handler func(*drive.Change) error) (newToken string, err error) {
+	newToken = token
+	for {
+		res, err := svc.changer.List(token).
+			Context(ctx).
+			IncludeItemsFromAllDrives(true).
+			SupportsAllDrives(true).
+			Fields(changeFields).
+			Do()
+		if err != nil {
+			return newToken, err
+		}
+		for _, c := range res.Changes {
+			if err := handler(c); err != nil {
+				return newToken, err
+			}
+		}
+		if res.NewStartPageToken != "" {
+			newToken = res.NewStartPageToken
+		}
+		if res.NextPageToken == "" {
+			return newToken, nil
+		}
+		token = res.NextPageToken
+	}
+}
+
+// Poll calls Changes every interval until ctx is done, loading and saving
+// its page token via store so progress survives restarts. If store has no
+// token yet (Load returns ""), Poll seeds it with StartPageToken so the
+// first tick only reports changes from that point forward. Poll returns
+// ctx.Err() when ctx is done, or the first error from Changes or store.
+func (svc *Service) Poll(ctx context.Context, interval time.Duration, store TokenStore, handler func(*drive.Change) error) error {
+	token, err := store.Load()
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		if token, err = svc.StartPageToken(); err != nil {
+			return err
+		}
+		if err := store.Save(token); err != nil {
+			return err
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			newToken, err := svc.Changes(ctx, token, handler)
+			if err != nil {
+				return err
+			}
+			if newToken != token {
+				token = newToken
+				if err := store.Save(token); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}